@@ -0,0 +1,1320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ObjectData is the in-memory representation of a stored GCS object.
+type ObjectData struct {
+	Data            []byte
+	ContentType     string
+	Metadata        map[string]string
+	CacheControl    string
+	ContentEncoding string
+	Generation      int64
+	Metageneration  int64
+	TimeCreated     time.Time
+	Updated         time.Time
+}
+
+// GCSObject mirrors the subset of the real GCS object resource JSON that
+// this mock supports.
+type GCSObject struct {
+	Kind            string            `json:"kind"`
+	ID              string            `json:"id"`
+	SelfLink        string            `json:"selfLink"`
+	Name            string            `json:"name"`
+	Bucket          string            `json:"bucket"`
+	ContentType     string            `json:"contentType"`
+	Size            string            `json:"size"`
+	TimeCreated     string            `json:"timeCreated"`
+	Updated         string            `json:"updated"`
+	Md5Hash         string            `json:"md5Hash"`
+	Etag            string            `json:"etag"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	CacheControl    string            `json:"cacheControl,omitempty"`
+	ContentEncoding string            `json:"contentEncoding,omitempty"`
+	Generation      string            `json:"generation"`
+	Metageneration  string            `json:"metageneration"`
+}
+
+// GCSListResponse is the response body for objects.list.
+type GCSListResponse struct {
+	Kind          string      `json:"kind"`
+	Items         []GCSObject `json:"items"`
+	Prefixes      []string    `json:"prefixes,omitempty"`
+	NextPageToken string      `json:"nextPageToken,omitempty"`
+}
+
+var (
+	storeMu       sync.RWMutex
+	inMemoryStore = make(map[string]map[string]ObjectData)
+
+	// bucketMu guards bucketVersioning, which tracks whether a bucket was
+	// created with versioning enabled.
+	bucketMu         sync.RWMutex
+	bucketVersioning = make(map[string]bool)
+
+	// historyMu guards generationHistory, the superseded generations of
+	// objects in versioning-enabled buckets. Live objects live in
+	// inMemoryStore; this only holds what a write replaced.
+	historyMu         sync.Mutex
+	generationHistory = make(map[string]map[string][]ObjectData)
+)
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+func createBucket(name string) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	inMemoryStore[name] = make(map[string]ObjectData)
+}
+
+func setBucketVersioning(name string, enabled bool) {
+	bucketMu.Lock()
+	defer bucketMu.Unlock()
+	bucketVersioning[name] = enabled
+}
+
+func bucketVersioningEnabled(name string) bool {
+	bucketMu.RLock()
+	defer bucketMu.RUnlock()
+	return bucketVersioning[name]
+}
+
+func bucketExists(name string) bool {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	_, ok := inMemoryStore[name]
+	return ok
+}
+
+func uploadObject(bucket, name string, data []byte, contentType string) {
+	uploadObjectWithMetadata(bucket, name, data, contentType, nil, "", "")
+}
+
+// objectUnchanged reports whether bucket/name already holds exactly data
+// and contentType, so callers that re-upload on every pass (like the
+// manifest live-reloader) can skip bumping the generation for files whose
+// bytes didn't actually change.
+func objectUnchanged(bucket, name string, data []byte, contentType string) bool {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	existing, ok := inMemoryStore[bucket][name]
+	return ok && existing.ContentType == contentType && bytes.Equal(existing.Data, data)
+}
+
+func uploadObjectWithMetadata(bucket, name string, data []byte, contentType string, metadata map[string]string, cacheControl, contentEncoding string) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	now := time.Now().UTC()
+	existing, ok := inMemoryStore[bucket][name]
+	timeCreated := now
+	generation, metageneration := int64(1), int64(1)
+	if ok {
+		timeCreated = existing.TimeCreated
+		generation = existing.Generation + 1
+		metageneration = existing.Metageneration + 1
+	}
+
+	inMemoryStore[bucket][name] = ObjectData{
+		Data:            data,
+		ContentType:     contentType,
+		Metadata:        metadata,
+		CacheControl:    cacheControl,
+		ContentEncoding: contentEncoding,
+		Generation:      generation,
+		Metageneration:  metageneration,
+		TimeCreated:     timeCreated,
+		Updated:         now,
+	}
+
+	if ok && bucketVersioningEnabled(bucket) {
+		historyMu.Lock()
+		if generationHistory[bucket] == nil {
+			generationHistory[bucket] = make(map[string][]ObjectData)
+		}
+		generationHistory[bucket][name] = append(generationHistory[bucket][name], existing)
+		historyMu.Unlock()
+	}
+}
+
+// enforcePreconditions checks the GCS ifGenerationMatch/ifGenerationNotMatch/
+// ifMetagenerationMatch/ifMetagenerationNotMatch query params against the
+// object's current generation (0 if it doesn't exist yet), writing a 400 or
+// 412 response and returning false on failure.
+func enforcePreconditions(w http.ResponseWriter, bucket, name string, query url.Values) bool {
+	storeMu.RLock()
+	existing, exists := inMemoryStore[bucket][name]
+	storeMu.RUnlock()
+
+	currentGeneration := int64(0)
+	if exists {
+		currentGeneration = existing.Generation
+	}
+
+	if v := query.Get("ifGenerationMatch"); v != "" {
+		want, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid ifGenerationMatch", http.StatusBadRequest)
+			return false
+		}
+		if currentGeneration != want {
+			http.Error(w, "precondition failed: generation mismatch", http.StatusPreconditionFailed)
+			return false
+		}
+	}
+	if v := query.Get("ifGenerationNotMatch"); v != "" {
+		want, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid ifGenerationNotMatch", http.StatusBadRequest)
+			return false
+		}
+		if currentGeneration == want {
+			http.Error(w, "precondition failed: generation matched", http.StatusPreconditionFailed)
+			return false
+		}
+	}
+
+	if exists {
+		if v := query.Get("ifMetagenerationMatch"); v != "" {
+			want, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid ifMetagenerationMatch", http.StatusBadRequest)
+				return false
+			}
+			if existing.Metageneration != want {
+				http.Error(w, "precondition failed: metageneration mismatch", http.StatusPreconditionFailed)
+				return false
+			}
+		}
+		if v := query.Get("ifMetagenerationNotMatch"); v != "" {
+			want, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid ifMetagenerationNotMatch", http.StatusBadRequest)
+				return false
+			}
+			if existing.Metageneration == want {
+				http.Error(w, "precondition failed: metageneration matched", http.StatusPreconditionFailed)
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func toGCSObject(bucket, name string, obj ObjectData) GCSObject {
+	sum := md5.Sum(obj.Data)
+	return GCSObject{
+		Kind:            "storage#object",
+		ID:              fmt.Sprintf("%s/%s", bucket, name),
+		SelfLink:        fmt.Sprintf("/storage/v1/b/%s/o/%s", bucket, name),
+		Name:            name,
+		Bucket:          bucket,
+		ContentType:     obj.ContentType,
+		Size:            fmt.Sprintf("%d", len(obj.Data)),
+		TimeCreated:     obj.TimeCreated.Format(time.RFC3339),
+		Updated:         obj.Updated.Format(time.RFC3339),
+		Md5Hash:         hex.EncodeToString(sum[:]),
+		Etag:            hex.EncodeToString(sum[:]),
+		Metadata:        obj.Metadata,
+		CacheControl:    obj.CacheControl,
+		ContentEncoding: obj.ContentEncoding,
+		Generation:      strconv.FormatInt(obj.Generation, 10),
+		Metageneration:  strconv.FormatInt(obj.Metageneration, 10),
+	}
+}
+
+func handleCreateBucket(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name       string `json:"name"`
+		Versioning *struct {
+			Enabled bool `json:"enabled"`
+		} `json:"versioning"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		http.Error(w, "invalid bucket request", http.StatusBadRequest)
+		return
+	}
+
+	if bucketExists(req.Name) {
+		http.Error(w, "bucket already exists", http.StatusConflict)
+		return
+	}
+
+	createBucket(req.Name)
+	if req.Versioning != nil && req.Versioning.Enabled {
+		setBucketVersioning(req.Name, true)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"kind": "storage#bucket",
+		"name": req.Name,
+	})
+}
+
+func handleUploadObject(w http.ResponseWriter, r *http.Request) {
+	bucket := r.PathValue("bucket")
+	if !bucketExists(bucket) {
+		http.Error(w, "bucket not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.URL.Query().Get("uploadType") {
+	case "resumable":
+		handleInitResumableUpload(w, r, bucket)
+		return
+	case "multipart":
+		handleMultipartUpload(w, r, bucket)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "missing name parameter", http.StatusBadRequest)
+		return
+	}
+
+	if !enforcePreconditions(w, bucket, name, r.URL.Query()) {
+		return
+	}
+
+	defer r.Body.Close()
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusInternalServerError)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	uploadObject(bucket, name, data, contentType)
+
+	storeMu.RLock()
+	obj := inMemoryStore[bucket][name]
+	storeMu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toGCSObject(bucket, name, obj))
+}
+
+// handleMultipartUpload handles uploadType=multipart requests, where the
+// body is a multipart/related payload of a JSON metadata part followed by
+// the raw object bytes.
+func handleMultipartUpload(w http.ResponseWriter, r *http.Request, bucket string) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		http.Error(w, "invalid multipart Content-Type", http.StatusBadRequest)
+		return
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		http.Error(w, "missing multipart boundary", http.StatusBadRequest)
+		return
+	}
+
+	reader := multipart.NewReader(r.Body, boundary)
+
+	metaPart, err := reader.NextPart()
+	if err != nil {
+		http.Error(w, "missing metadata part", http.StatusBadRequest)
+		return
+	}
+	var meta struct {
+		Name            string            `json:"name"`
+		ContentType     string            `json:"contentType"`
+		Metadata        map[string]string `json:"metadata"`
+		CacheControl    string            `json:"cacheControl"`
+		ContentEncoding string            `json:"contentEncoding"`
+	}
+	if err := json.NewDecoder(metaPart).Decode(&meta); err != nil {
+		http.Error(w, "invalid metadata part", http.StatusBadRequest)
+		return
+	}
+
+	dataPart, err := reader.NextPart()
+	if err != nil {
+		http.Error(w, "missing object data part", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(dataPart)
+	if err != nil {
+		http.Error(w, "failed to read object data part", http.StatusInternalServerError)
+		return
+	}
+
+	queryName := r.URL.Query().Get("name")
+	if queryName != "" && meta.Name != "" && queryName != meta.Name {
+		http.Error(w, "name query parameter does not match metadata name", http.StatusBadRequest)
+		return
+	}
+	name := queryName
+	if name == "" {
+		name = meta.Name
+	}
+	if name == "" {
+		http.Error(w, "missing object name", http.StatusBadRequest)
+		return
+	}
+
+	contentType := meta.ContentType
+	if contentType == "" {
+		contentType = dataPart.Header.Get("Content-Type")
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	if !enforcePreconditions(w, bucket, name, r.URL.Query()) {
+		return
+	}
+
+	uploadObjectWithMetadata(bucket, name, data, contentType, meta.Metadata, meta.CacheControl, meta.ContentEncoding)
+
+	storeMu.RLock()
+	obj := inMemoryStore[bucket][name]
+	storeMu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toGCSObject(bucket, name, obj))
+}
+
+// resumableUpload tracks the state of an in-progress resumable upload
+// session between the initiating POST and the chunked PUTs that follow it.
+type resumableUpload struct {
+	Bucket      string
+	Name        string
+	ContentType string
+	TotalSize   int64 // -1 until the client tells us the final size
+	Data        []byte
+	Offset      int64
+	ExpiresAt   time.Time
+	// Preconditions are captured from the initiating POST, since that's
+	// where a real GCS client sets ifGenerationMatch and friends.
+	Preconditions url.Values
+}
+
+var (
+	resumableMu    sync.Mutex
+	resumableStore = make(map[string]*resumableUpload)
+
+	// resumableUploadTTL controls how long a resumable session stays valid
+	// without a chunk being uploaded. Overridable via the -resumable-ttl flag.
+	resumableUploadTTL = 7 * 24 * time.Hour
+)
+
+// handleInitResumableUpload handles the initial POST of a resumable upload:
+// it records a new session and points the client at the PUT URL to stream
+// chunks to, mirroring the real `Location` header response.
+func handleInitResumableUpload(w http.ResponseWriter, r *http.Request, bucket string) {
+	var meta struct {
+		Name        string `json:"name"`
+		ContentType string `json:"contentType"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&meta); err != nil {
+			http.Error(w, "invalid metadata body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = meta.Name
+	}
+	if name == "" {
+		http.Error(w, "missing object name", http.StatusBadRequest)
+		return
+	}
+
+	contentType := r.Header.Get("X-Upload-Content-Type")
+	if contentType == "" {
+		contentType = meta.ContentType
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	totalSize := int64(-1)
+	if v := r.Header.Get("X-Upload-Content-Length"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			totalSize = n
+		}
+	}
+
+	id := newResumableSessionID()
+
+	resumableMu.Lock()
+	resumableStore[id] = &resumableUpload{
+		Bucket:        bucket,
+		Name:          name,
+		ContentType:   contentType,
+		TotalSize:     totalSize,
+		ExpiresAt:     time.Now().Add(resumableUploadTTL),
+		Preconditions: r.URL.Query(),
+	}
+	resumableMu.Unlock()
+
+	w.Header().Set("Location", fmt.Sprintf("/upload/storage/v1/b/%s/o?upload_id=%s", bucket, id))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleResumableUploadChunk handles the PUTs to a resumable session URL,
+// appending the Content-Range chunk at the expected offset and either
+// asking for more data (308 Resume Incomplete) or finalizing the object.
+func handleResumableUploadChunk(w http.ResponseWriter, r *http.Request) {
+	bucket := r.PathValue("bucket")
+	id := r.URL.Query().Get("upload_id")
+
+	resumableMu.Lock()
+	session, ok := resumableStore[id]
+	if ok && time.Now().After(session.ExpiresAt) {
+		delete(resumableStore, id)
+		ok = false
+	}
+	resumableMu.Unlock()
+
+	if !ok || session.Bucket != bucket {
+		http.Error(w, "unknown or expired upload session", http.StatusNotFound)
+		return
+	}
+
+	cr, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		http.Error(w, "invalid Content-Range header", http.StatusBadRequest)
+		return
+	}
+
+	if cr.statusOnly {
+		resumableMu.Lock()
+		offset := session.Offset
+		resumableMu.Unlock()
+		respondResumableStatus(w, offset)
+		return
+	}
+
+	defer r.Body.Close()
+	chunk, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read chunk", http.StatusInternalServerError)
+		return
+	}
+
+	resumableMu.Lock()
+	defer resumableMu.Unlock()
+
+	if cr.start != session.Offset {
+		// GCS signals an offset mismatch with the non-standard 499 status
+		// so clients know to re-query status rather than retry blindly.
+		w.WriteHeader(499)
+		return
+	}
+
+	session.Data = append(session.Data, chunk...)
+	session.Offset += int64(len(chunk))
+	if cr.total >= 0 {
+		session.TotalSize = cr.total
+	}
+
+	if session.TotalSize >= 0 && session.Offset >= session.TotalSize {
+		if !enforcePreconditions(w, bucket, session.Name, session.Preconditions) {
+			delete(resumableStore, id)
+			return
+		}
+
+		uploadObject(bucket, session.Name, session.Data, session.ContentType)
+		delete(resumableStore, id)
+
+		storeMu.RLock()
+		obj := inMemoryStore[bucket][session.Name]
+		storeMu.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toGCSObject(bucket, session.Name, obj))
+		return
+	}
+
+	respondResumableStatus(w, session.Offset)
+}
+
+// respondResumableStatus replies 308 Resume Incomplete with the Range of
+// bytes received so far, the response GCS clients poll for to learn where
+// to resume an interrupted upload.
+func respondResumableStatus(w http.ResponseWriter, offset int64) {
+	if offset > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", offset-1))
+	}
+	w.WriteHeader(http.StatusPermanentRedirect)
+}
+
+// contentRange is a parsed `Content-Range: bytes start-end/total` header, or
+// a `bytes */total` status query with statusOnly set.
+type contentRange struct {
+	start      int64
+	end        int64
+	total      int64 // -1 when the total is "*"
+	statusOnly bool
+}
+
+func parseContentRange(header string) (contentRange, error) {
+	const prefix = "bytes "
+	if !strings.HasPrefix(header, prefix) {
+		return contentRange{}, fmt.Errorf("missing %q unit in Content-Range", "bytes")
+	}
+
+	rangePart, totalPart, ok := strings.Cut(strings.TrimPrefix(header, prefix), "/")
+	if !ok {
+		return contentRange{}, fmt.Errorf("missing total size in Content-Range")
+	}
+
+	cr := contentRange{total: -1}
+	if totalPart != "*" {
+		total, err := strconv.ParseInt(totalPart, 10, 64)
+		if err != nil {
+			return contentRange{}, fmt.Errorf("invalid total size: %w", err)
+		}
+		cr.total = total
+	}
+
+	if rangePart == "*" {
+		cr.statusOnly = true
+		return cr, nil
+	}
+
+	startStr, endStr, ok := strings.Cut(rangePart, "-")
+	if !ok {
+		return contentRange{}, fmt.Errorf("invalid byte range")
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return contentRange{}, fmt.Errorf("invalid range start: %w", err)
+	}
+	end, err := strconv.ParseInt(endStr, 10, 64)
+	if err != nil {
+		return contentRange{}, fmt.Errorf("invalid range end: %w", err)
+	}
+	cr.start, cr.end = start, end
+	return cr, nil
+}
+
+func newResumableSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().UTC().String()))
+	}
+	return hex.EncodeToString(b)
+}
+
+func handleGetObject(w http.ResponseWriter, r *http.Request) {
+	bucket := r.PathValue("bucket")
+	name := r.PathValue("object")
+
+	obj, bucketOK, objOK := lookupObjectGeneration(bucket, name, r.URL.Query().Get("generation"))
+	if !bucketOK || !objOK {
+		http.Error(w, "object not found", http.StatusNotFound)
+		return
+	}
+
+	etag := objectETag(obj)
+	lastModified := obj.Updated
+
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if match := r.Header.Get("If-Match"); match != "" && !etagMatches(match, etag) {
+		http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+	if v := r.Header.Get("If-Modified-Since"); v != "" {
+		if t, err := http.ParseTime(v); err == nil && !lastModified.After(t) {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	if v := r.Header.Get("If-Unmodified-Since"); v != "" {
+		if t, err := http.ParseTime(v); err == nil && lastModified.After(t) {
+			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	total := len(obj.Data)
+
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		start, end, ok := parseRangeHeader(rangeHeader, total)
+		if !ok {
+			http.Error(w, "invalid Range header", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, total))
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(obj.Data[start : end+1])
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(total))
+	w.Write(obj.Data)
+}
+
+func objectETag(obj ObjectData) string {
+	sum := md5.Sum(obj.Data)
+	return hex.EncodeToString(sum[:])
+}
+
+// etagMatches reports whether etag satisfies an If-Match/If-None-Match
+// header, which may be "*" or a comma-separated list of quoted ETags.
+func etagMatches(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.Trim(strings.TrimSpace(candidate), `"`) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRangeHeader parses a single-range `Range: bytes=...` header (only
+// the first range is honored if several are given) into an inclusive
+// [start, end] byte span, supporting the start-end, start- and -suffixLen
+// forms.
+func parseRangeHeader(header string, total int) (start, end int, ok bool) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return 0, 0, false
+	}
+
+	spec := strings.Split(strings.TrimPrefix(header, prefix), ",")[0]
+	startStr, endStr, found := strings.Cut(spec, "-")
+	if !found {
+		return 0, 0, false
+	}
+
+	switch {
+	case startStr == "" && endStr != "":
+		n, err := strconv.Atoi(endStr)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > total {
+			n = total
+		}
+		start, end = total-n, total-1
+	case startStr != "" && endStr == "":
+		n, err := strconv.Atoi(startStr)
+		if err != nil {
+			return 0, 0, false
+		}
+		start, end = n, total-1
+	case startStr != "" && endStr != "":
+		s, err1 := strconv.Atoi(startStr)
+		e, err2 := strconv.Atoi(endStr)
+		if err1 != nil || err2 != nil {
+			return 0, 0, false
+		}
+		start, end = s, e
+		if end > total-1 {
+			end = total - 1
+		}
+	default:
+		return 0, 0, false
+	}
+
+	if start < 0 || start >= total || end < start {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// lookupObjectGeneration resolves an object, optionally pinned to a specific
+// historical generation. An empty generationParam returns the live object.
+func lookupObjectGeneration(bucket, name, generationParam string) (obj ObjectData, bucketOK, objOK bool) {
+	storeMu.RLock()
+	objects, bOK := inMemoryStore[bucket]
+	bucketOK = bOK
+	if bOK {
+		obj, objOK = objects[name]
+	}
+	storeMu.RUnlock()
+
+	if !bucketOK || generationParam == "" {
+		return obj, bucketOK, objOK
+	}
+
+	want, err := strconv.ParseInt(generationParam, 10, 64)
+	if err != nil {
+		return ObjectData{}, bucketOK, false
+	}
+	if objOK && obj.Generation == want {
+		return obj, bucketOK, true
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	for _, hist := range generationHistory[bucket][name] {
+		if hist.Generation == want {
+			return hist, bucketOK, true
+		}
+	}
+	return ObjectData{}, bucketOK, false
+}
+
+// handleDeleteObject implements DELETE /storage/v1/b/{bucket}/o/{object...},
+// honoring the same generation/metageneration preconditions as uploads.
+func handleDeleteObject(w http.ResponseWriter, r *http.Request) {
+	bucket := r.PathValue("bucket")
+	name := r.PathValue("object")
+
+	if !bucketExists(bucket) {
+		http.Error(w, "bucket not found", http.StatusNotFound)
+		return
+	}
+
+	if !enforcePreconditions(w, bucket, name, r.URL.Query()) {
+		return
+	}
+
+	storeMu.Lock()
+	_, existed := inMemoryStore[bucket][name]
+	delete(inMemoryStore[bucket], name)
+	storeMu.Unlock()
+
+	if !existed {
+		http.Error(w, "object not found", http.StatusNotFound)
+		return
+	}
+
+	historyMu.Lock()
+	if generationHistory[bucket] != nil {
+		delete(generationHistory[bucket], name)
+	}
+	historyMu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleListObjects(w http.ResponseWriter, r *http.Request) {
+	bucket := r.PathValue("bucket")
+
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	startOffset := query.Get("startOffset")
+	endOffset := query.Get("endOffset")
+	// Object versioning isn't modeled yet (see the Generation field added
+	// alongside bucket versioning), so the versions param is accepted but
+	// has no effect: every object only ever has its live version.
+	_ = query.Get("versions")
+
+	maxResults := 1000
+	if v := query.Get("maxResults"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			maxResults = n
+		}
+	}
+
+	var afterKey string
+	if token := query.Get("pageToken"); token != "" {
+		decoded, err := decodePageToken(token)
+		if err != nil {
+			http.Error(w, "invalid pageToken", http.StatusBadRequest)
+			return
+		}
+		afterKey = decoded
+	}
+
+	storeMu.RLock()
+	objects, ok := inMemoryStore[bucket]
+	if !ok {
+		storeMu.RUnlock()
+		http.Error(w, "bucket not found", http.StatusNotFound)
+		return
+	}
+
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		if prefix != "" && !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if startOffset != "" && name < startOffset {
+			continue
+		}
+		if endOffset != "" && name >= endOffset {
+			continue
+		}
+		if afterKey != "" && name <= afterKey {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := make([]GCSObject, 0, len(names))
+	var prefixes []string
+	seenPrefixes := make(map[string]bool)
+	var nextPageToken string
+
+	// lastGroupEnd is the index of the last name collapsed into the most
+	// recently emitted prefix, so the pageToken cursor can skip the whole
+	// group instead of landing mid-group and re-emitting the same prefix
+	// on the next page.
+	lastGroupEnd := -1
+
+	for i, name := range names {
+		if len(items)+len(prefixes) >= maxResults {
+			cursor := names[i-1]
+			if lastGroupEnd != -1 {
+				cursor = names[lastGroupEnd]
+			}
+			nextPageToken = encodePageToken(cursor)
+			break
+		}
+
+		if delimiter != "" {
+			remainder := strings.TrimPrefix(name, prefix)
+			if idx := strings.Index(remainder, delimiter); idx >= 0 {
+				p := prefix + remainder[:idx+len(delimiter)]
+				if !seenPrefixes[p] {
+					seenPrefixes[p] = true
+					prefixes = append(prefixes, p)
+
+					j := i
+					for j+1 < len(names) && strings.HasPrefix(names[j+1], p) {
+						j++
+					}
+					lastGroupEnd = j
+				}
+				continue
+			}
+		}
+
+		lastGroupEnd = -1
+		items = append(items, toGCSObject(bucket, name, objects[name]))
+	}
+	storeMu.RUnlock()
+
+	sort.Strings(prefixes)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(GCSListResponse{
+		Kind:          "storage#objects",
+		Items:         items,
+		Prefixes:      prefixes,
+		NextPageToken: nextPageToken,
+	})
+}
+
+func encodePageToken(lastKey string) string {
+	return base64.StdEncoding.EncodeToString([]byte(lastKey))
+}
+
+func decodePageToken(token string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// File describes a single object entry in a bucket's manifest. Exactly one
+// of Path, Glob, or Dir should be set: Path names a single file, Glob
+// expands to every file matching the pattern, and Dir is walked recursively.
+type File struct {
+	Path        string
+	Glob        string
+	Dir         string
+	ContentType string
+}
+
+// Bucket describes a bucket's contents within a manifest.
+type Bucket struct {
+	Files []File
+}
+
+// Manifest is the on-disk description of the buckets and objects a mock
+// instance should be seeded with.
+type Manifest struct {
+	Buckets map[string]Bucket
+}
+
+// readManifest parses the small, purpose-built YAML subset used to describe
+// buckets and their files:
+//
+//	buckets:
+//	  bucket-name:
+//	    files:
+//	      - path: ./data.json
+//	        content-type: application/json
+//	      - glob: ./fixtures/*.json
+//	      - dir: ./fixtures/nested
+func readManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest %q: %w", path, err)
+	}
+
+	manifest := &Manifest{Buckets: make(map[string]Bucket)}
+
+	var currentBucket string
+	var files []File
+	var currentFile *File
+
+	flushFile := func() {
+		if currentFile != nil {
+			files = append(files, *currentFile)
+			currentFile = nil
+		}
+	}
+	flushBucket := func() {
+		flushFile()
+		if currentBucket != "" {
+			manifest.Buckets[currentBucket] = Bucket{Files: files}
+		}
+		files = nil
+	}
+
+	for _, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimRight(raw, " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "buckets:" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		switch {
+		case indent == 2 && trimmed != "files:" && strings.HasSuffix(trimmed, ":"):
+			flushBucket()
+			currentBucket = strings.TrimSuffix(trimmed, ":")
+		case trimmed == "files:":
+			flushFile()
+		case strings.HasPrefix(trimmed, "- "):
+			flushFile()
+			currentFile = &File{}
+			setManifestField(currentFile, strings.TrimPrefix(trimmed, "- "))
+		case currentFile != nil:
+			setManifestField(currentFile, trimmed)
+		}
+	}
+	flushBucket()
+
+	return manifest, nil
+}
+
+func setManifestField(f *File, kv string) {
+	key, value, ok := strings.Cut(kv, ":")
+	if !ok {
+		return
+	}
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+	switch key {
+	case "path":
+		f.Path = value
+	case "glob":
+		f.Glob = value
+	case "dir":
+		f.Dir = value
+	case "content-type":
+		f.ContentType = value
+	}
+}
+
+// manifestManaged tracks, per bucket, the object names last applied from a
+// manifest, so a reload can tell which objects were removed from disk and
+// need deleting rather than just which ones to add or replace.
+var (
+	manifestMu      sync.Mutex
+	manifestManaged = make(map[string]map[string]bool)
+)
+
+// manifestFileEntry is a single concrete file resolved from a File's path,
+// glob, or dir entry, paired with the object name it should be stored as.
+type manifestFileEntry struct {
+	absPath    string
+	objectName string
+}
+
+// expandManifestFile resolves a single manifest File entry to the concrete
+// files it describes, deriving each object's name from its path relative to
+// the manifest directory.
+func expandManifestFile(manifestDir string, file File) ([]manifestFileEntry, error) {
+	switch {
+	case file.Glob != "":
+		pattern := resolveManifestPath(manifestDir, file.Glob)
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", file.Glob, err)
+		}
+		entries := make([]manifestFileEntry, 0, len(matches))
+		for _, m := range matches {
+			entries = append(entries, manifestFileEntry{absPath: m, objectName: objectNameFor(manifestDir, m)})
+		}
+		return entries, nil
+
+	case file.Dir != "":
+		root := resolveManifestPath(manifestDir, file.Dir)
+		var entries []manifestFileEntry
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			entries = append(entries, manifestFileEntry{absPath: path, objectName: objectNameFor(manifestDir, path)})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walking manifest dir %q: %w", file.Dir, err)
+		}
+		return entries, nil
+
+	default:
+		abs := resolveManifestPath(manifestDir, file.Path)
+		return []manifestFileEntry{{absPath: abs, objectName: objectNameFor(manifestDir, abs)}}, nil
+	}
+}
+
+func resolveManifestPath(manifestDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(manifestDir, path)
+}
+
+func objectNameFor(manifestDir, absPath string) string {
+	if rel, err := filepath.Rel(manifestDir, absPath); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.Base(absPath)
+}
+
+// processManifest applies manifest to inMemoryStore relative to
+// manifestDir, creating buckets as needed. Objects that were created by a
+// previous call but are no longer described by manifest are deleted, so
+// repeated calls (as the live-reload watcher makes) converge the store to
+// exactly what's on disk.
+func processManifest(manifestDir string, manifest *Manifest) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	desired := make(map[string]map[string]bool, len(manifest.Buckets))
+	for bucketName, bucket := range manifest.Buckets {
+		if !bucketExists(bucketName) {
+			createBucket(bucketName)
+		}
+		if desired[bucketName] == nil {
+			desired[bucketName] = make(map[string]bool)
+		}
+
+		for _, file := range bucket.Files {
+			entries, err := expandManifestFile(manifestDir, file)
+			if err != nil {
+				return err
+			}
+			contentType := file.ContentType
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			for _, entry := range entries {
+				data, err := os.ReadFile(entry.absPath)
+				if err != nil {
+					return fmt.Errorf("reading manifest file %q: %w", entry.absPath, err)
+				}
+				if !objectUnchanged(bucketName, entry.objectName, data, contentType) {
+					uploadObject(bucketName, entry.objectName, data, contentType)
+				}
+				desired[bucketName][entry.objectName] = true
+			}
+		}
+	}
+
+	for bucketName, names := range manifestManaged {
+		for name := range names {
+			if !desired[bucketName][name] {
+				storeMu.Lock()
+				delete(inMemoryStore[bucketName], name)
+				storeMu.Unlock()
+			}
+		}
+	}
+	manifestManaged = desired
+
+	return nil
+}
+
+// manifestWatchDirs lists every directory that needs an fsnotify watch to
+// notice changes to manifest itself or any file/glob/dir it references.
+func manifestWatchDirs(manifestPath string, manifest *Manifest) []string {
+	manifestDir := filepath.Dir(manifestPath)
+	dirs := map[string]bool{manifestDir: true}
+
+	for _, bucket := range manifest.Buckets {
+		for _, file := range bucket.Files {
+			switch {
+			case file.Dir != "":
+				root := resolveManifestPath(manifestDir, file.Dir)
+				filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+					if err == nil && d.IsDir() {
+						dirs[path] = true
+					}
+					return nil
+				})
+			case file.Glob != "":
+				dirs[filepath.Dir(resolveManifestPath(manifestDir, file.Glob))] = true
+			default:
+				dirs[filepath.Dir(resolveManifestPath(manifestDir, file.Path))] = true
+			}
+		}
+	}
+
+	result := make([]string, 0, len(dirs))
+	for d := range dirs {
+		result = append(result, d)
+	}
+	return result
+}
+
+// watchManifest re-applies manifestPath to the store whenever it, or any
+// file/glob/dir entry it references, changes on disk. It blocks until ctx
+// is canceled.
+func watchManifest(ctx context.Context, manifestPath string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("creating manifest watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	manifestDir := filepath.Dir(manifestPath)
+	watched := make(map[string]bool)
+
+	addWatches := func(manifest *Manifest) {
+		for _, dir := range manifestWatchDirs(manifestPath, manifest) {
+			if watched[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				log.Printf("manifest watcher: failed to watch %s: %v", dir, err)
+				continue
+			}
+			watched[dir] = true
+		}
+	}
+
+	if manifest, err := readManifest(manifestPath); err == nil {
+		addWatches(manifest)
+	}
+
+	reload := func() {
+		manifest, err := readManifest(manifestPath)
+		if err != nil {
+			log.Printf("manifest reload failed: %v", err)
+			return
+		}
+		if err := processManifest(manifestDir, manifest); err != nil {
+			log.Printf("manifest reload failed: %v", err)
+			return
+		}
+		addWatches(manifest)
+		log.Printf("reloaded manifest from %s", manifestPath)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+				reload()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("manifest watcher error: %v", err)
+		}
+	}
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	manifestPath := flag.String("manifest", "", "path to a manifest file to seed the store from")
+	resumableTTL := flag.Duration("resumable-ttl", 7*24*time.Hour, "how long a resumable upload session stays valid before expiring")
+	flag.Parse()
+
+	resumableUploadTTL = *resumableTTL
+
+	if *manifestPath != "" {
+		manifestDir := filepath.Dir(*manifestPath)
+		manifest, err := readManifest(*manifestPath)
+		if err != nil {
+			log.Fatalf("failed to read manifest: %v", err)
+		}
+		if err := processManifest(manifestDir, manifest); err != nil {
+			log.Fatalf("failed to process manifest: %v", err)
+		}
+
+		go func() {
+			if err := watchManifest(context.Background(), *manifestPath); err != nil {
+				log.Printf("manifest watcher stopped: %v", err)
+			}
+		}()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("GET /storage/v1/b/{bucket}/o", handleListObjects)
+	mux.HandleFunc("GET /storage/v1/b/{bucket}/o/{object...}", handleGetObject)
+	mux.HandleFunc("DELETE /storage/v1/b/{bucket}/o/{object...}", handleDeleteObject)
+	mux.HandleFunc("POST /storage/v1/b", handleCreateBucket)
+	mux.HandleFunc("POST /upload/storage/v1/b/{bucket}/o", handleUploadObject)
+	mux.HandleFunc("PUT /upload/storage/v1/b/{bucket}/o", handleResumableUploadChunk)
+
+	log.Printf("gcs-mock-service listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
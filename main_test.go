@@ -3,9 +3,12 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"testing"
@@ -14,13 +17,18 @@ import (
 func setupTestServer() *http.ServeMux {
 	// clear the store before each test
 	inMemoryStore = make(map[string]map[string]ObjectData)
+	bucketVersioning = make(map[string]bool)
+	generationHistory = make(map[string]map[string][]ObjectData)
+	manifestManaged = make(map[string]map[string]bool)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", healthHandler)
 	mux.HandleFunc("GET /storage/v1/b/{bucket}/o", handleListObjects)
 	mux.HandleFunc("GET /storage/v1/b/{bucket}/o/{object...}", handleGetObject)
+	mux.HandleFunc("DELETE /storage/v1/b/{bucket}/o/{object...}", handleDeleteObject)
 	mux.HandleFunc("POST /storage/v1/b", handleCreateBucket)
 	mux.HandleFunc("POST /upload/storage/v1/b/{bucket}/o", handleUploadObject)
+	mux.HandleFunc("PUT /upload/storage/v1/b/{bucket}/o", handleResumableUploadChunk)
 	return mux
 }
 
@@ -234,7 +242,7 @@ func TestProcessManifest(t *testing.T) {
 		},
 	}
 
-	if err := processManifest(manifest); err != nil {
+	if err := processManifest(dir, manifest); err != nil {
 		t.Fatalf("failed to process manifest: %v", err)
 	}
 
@@ -260,6 +268,135 @@ func TestProcessManifest(t *testing.T) {
 	}
 }
 
+func TestProcessManifestGlobAndDir(t *testing.T) {
+	inMemoryStore = make(map[string]map[string]ObjectData)
+	manifestManaged = make(map[string]map[string]bool)
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0o755); err != nil {
+		t.Fatalf("failed to create nested dir: %v", err)
+	}
+	files := map[string]string{
+		"a.json":        `{"a": 1}`,
+		"b.json":        `{"b": 2}`,
+		"nested/c.json": `{"c": 3}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	manifest := &Manifest{
+		Buckets: map[string]Bucket{
+			"manifest-bucket": {
+				Files: []File{
+					{Glob: filepath.Join(dir, "*.json")},
+					{Dir: filepath.Join(dir, "nested")},
+				},
+			},
+		},
+	}
+
+	if err := processManifest(dir, manifest); err != nil {
+		t.Fatalf("failed to process manifest: %v", err)
+	}
+
+	bucket := inMemoryStore["manifest-bucket"]
+	for _, name := range []string{"a.json", "b.json", "nested/c.json"} {
+		if _, ok := bucket[name]; !ok {
+			t.Errorf("expected object %q to exist", name)
+		}
+	}
+}
+
+func TestProcessManifestReconcilesRemovedFiles(t *testing.T) {
+	inMemoryStore = make(map[string]map[string]ObjectData)
+	manifestManaged = make(map[string]map[string]bool)
+
+	dir := t.TempDir()
+	keepPath := filepath.Join(dir, "keep.json")
+	dropPath := filepath.Join(dir, "drop.json")
+	if err := os.WriteFile(keepPath, []byte(`{}`), 0o644); err != nil {
+		t.Fatalf("failed to write keep.json: %v", err)
+	}
+	if err := os.WriteFile(dropPath, []byte(`{}`), 0o644); err != nil {
+		t.Fatalf("failed to write drop.json: %v", err)
+	}
+
+	manifest := &Manifest{
+		Buckets: map[string]Bucket{
+			"manifest-bucket": {
+				Files: []File{
+					{Path: keepPath},
+					{Path: dropPath},
+				},
+			},
+		},
+	}
+	if err := processManifest(dir, manifest); err != nil {
+		t.Fatalf("failed to process manifest: %v", err)
+	}
+	if _, ok := inMemoryStore["manifest-bucket"]["drop.json"]; !ok {
+		t.Fatal("expected drop.json to exist after first apply")
+	}
+
+	// reload with drop.json no longer described by the manifest
+	manifest.Buckets["manifest-bucket"] = Bucket{Files: []File{{Path: keepPath}}}
+	if err := processManifest(dir, manifest); err != nil {
+		t.Fatalf("failed to reprocess manifest: %v", err)
+	}
+
+	if _, ok := inMemoryStore["manifest-bucket"]["drop.json"]; ok {
+		t.Error("expected drop.json to be removed after reload no longer describes it")
+	}
+	if _, ok := inMemoryStore["manifest-bucket"]["keep.json"]; !ok {
+		t.Error("expected keep.json to still exist after reload")
+	}
+}
+
+func TestProcessManifestSkipsUnchangedFiles(t *testing.T) {
+	inMemoryStore = make(map[string]map[string]ObjectData)
+	manifestManaged = make(map[string]map[string]bool)
+
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "data.json")
+	if err := os.WriteFile(dataPath, []byte(`{"v": 1}`), 0o644); err != nil {
+		t.Fatalf("failed to write data.json: %v", err)
+	}
+
+	manifest := &Manifest{
+		Buckets: map[string]Bucket{
+			"manifest-bucket": {
+				Files: []File{{Path: dataPath, ContentType: "application/json"}},
+			},
+		},
+	}
+	if err := processManifest(dir, manifest); err != nil {
+		t.Fatalf("failed to process manifest: %v", err)
+	}
+	firstGeneration := inMemoryStore["manifest-bucket"]["data.json"].Generation
+
+	// reload with the file untouched should not bump the generation
+	if err := processManifest(dir, manifest); err != nil {
+		t.Fatalf("failed to reprocess manifest: %v", err)
+	}
+	if got := inMemoryStore["manifest-bucket"]["data.json"].Generation; got != firstGeneration {
+		t.Errorf("expected generation to stay at %d for an unchanged file, got %d", firstGeneration, got)
+	}
+
+	// reload after the bytes actually changed should bump the generation
+	if err := os.WriteFile(dataPath, []byte(`{"v": 2}`), 0o644); err != nil {
+		t.Fatalf("failed to rewrite data.json: %v", err)
+	}
+	if err := processManifest(dir, manifest); err != nil {
+		t.Fatalf("failed to reprocess manifest: %v", err)
+	}
+	if got := inMemoryStore["manifest-bucket"]["data.json"].Generation; got != firstGeneration+1 {
+		t.Errorf("expected generation %d after a real change, got %d", firstGeneration+1, got)
+	}
+}
+
 func TestDefaultContentType(t *testing.T) {
 	mux := setupTestServer()
 	createBucket("ct-bucket")
@@ -279,3 +416,426 @@ func TestDefaultContentType(t *testing.T) {
 		t.Errorf("expected default content-type 'application/octet-stream', got '%s'", obj.ContentType)
 	}
 }
+
+// initResumableUpload starts a resumable session and returns its upload_id.
+func initResumableUpload(t *testing.T, mux *http.ServeMux, bucket, name string, size int) string {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", fmt.Sprintf("/upload/storage/v1/b/%s/o?uploadType=resumable&name=%s", bucket, name), nil)
+	req.Header.Set("X-Upload-Content-Type", "application/octet-stream")
+	req.Header.Set("X-Upload-Content-Length", fmt.Sprintf("%d", size))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("init resumable upload: expected status 201, got %d", w.Code)
+	}
+
+	location := w.Header().Get("Location")
+	parsed, err := url.Parse(location)
+	if err != nil {
+		t.Fatalf("failed to parse Location header %q: %v", location, err)
+	}
+	id := parsed.Query().Get("upload_id")
+	if id == "" {
+		t.Fatalf("Location header %q missing upload_id", location)
+	}
+	return id
+}
+
+func TestResumableUploadSingleChunk(t *testing.T) {
+	mux := setupTestServer()
+	createBucket("resumable-bucket")
+
+	content := "0123456789"
+	id := initResumableUpload(t, mux, "resumable-bucket", "big.bin", len(content))
+
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/upload/storage/v1/b/resumable-bucket/o?upload_id=%s", id), bytes.NewBufferString(content))
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(content)-1, len(content)))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 on final chunk, got %d", w.Code)
+	}
+
+	obj := inMemoryStore["resumable-bucket"]["big.bin"]
+	if string(obj.Data) != content {
+		t.Errorf("expected stored data %q, got %q", content, string(obj.Data))
+	}
+}
+
+func TestResumableUploadMultipleChunksAndStatusQuery(t *testing.T) {
+	mux := setupTestServer()
+	createBucket("resumable-bucket")
+
+	content := "0123456789"
+	id := initResumableUpload(t, mux, "resumable-bucket", "big.bin", len(content))
+
+	// first chunk: bytes 0-4
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/upload/storage/v1/b/resumable-bucket/o?upload_id=%s", id), bytes.NewBufferString(content[:5]))
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes 0-4/%d", len(content)))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPermanentRedirect {
+		t.Fatalf("expected status 308 after partial chunk, got %d", w.Code)
+	}
+	if got := w.Header().Get("Range"); got != "bytes=0-4" {
+		t.Errorf("expected Range 'bytes=0-4', got %q", got)
+	}
+
+	// status query
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/upload/storage/v1/b/resumable-bucket/o?upload_id=%s", id), nil)
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", len(content)))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPermanentRedirect {
+		t.Fatalf("expected status 308 on status query, got %d", w.Code)
+	}
+	if got := w.Header().Get("Range"); got != "bytes=0-4" {
+		t.Errorf("expected Range 'bytes=0-4' on status query, got %q", got)
+	}
+
+	// final chunk: bytes 5-9
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/upload/storage/v1/b/resumable-bucket/o?upload_id=%s", id), bytes.NewBufferString(content[5:]))
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes 5-9/%d", len(content)))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 on final chunk, got %d", w.Code)
+	}
+
+	obj := inMemoryStore["resumable-bucket"]["big.bin"]
+	if string(obj.Data) != content {
+		t.Errorf("expected stored data %q, got %q", content, string(obj.Data))
+	}
+}
+
+func TestResumableUploadOffsetMismatch(t *testing.T) {
+	mux := setupTestServer()
+	createBucket("resumable-bucket")
+
+	id := initResumableUpload(t, mux, "resumable-bucket", "big.bin", 10)
+
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/upload/storage/v1/b/resumable-bucket/o?upload_id=%s", id), bytes.NewBufferString("56789"))
+	req.Header.Set("Content-Range", "bytes 5-9/10")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != 499 {
+		t.Errorf("expected status 499 for offset mismatch, got %d", w.Code)
+	}
+}
+
+func TestMultipartUpload(t *testing.T) {
+	mux := setupTestServer()
+	createBucket("multipart-bucket")
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	metaPart, _ := writer.CreatePart(map[string][]string{"Content-Type": {"application/json"}})
+	metaPart.Write([]byte(`{"name": "doc.txt", "contentType": "text/plain", "metadata": {"owner": "team-a"}}`))
+
+	dataPart, _ := writer.CreatePart(map[string][]string{"Content-Type": {"text/plain"}})
+	dataPart.Write([]byte("hello multipart"))
+
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/upload/storage/v1/b/multipart-bucket/o?uploadType=multipart", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp GCSObject
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Name != "doc.txt" || resp.ContentType != "text/plain" {
+		t.Errorf("unexpected object resource: %+v", resp)
+	}
+	if resp.Metadata["owner"] != "team-a" {
+		t.Errorf("expected metadata owner=team-a, got %+v", resp.Metadata)
+	}
+
+	obj := inMemoryStore["multipart-bucket"]["doc.txt"]
+	if string(obj.Data) != "hello multipart" {
+		t.Errorf("expected stored data 'hello multipart', got %q", string(obj.Data))
+	}
+}
+
+func TestListObjectsPrefixAndDelimiter(t *testing.T) {
+	mux := setupTestServer()
+	createBucket("tree-bucket")
+	uploadObject("tree-bucket", "a.txt", []byte("a"), "text/plain")
+	uploadObject("tree-bucket", "dir/b.txt", []byte("b"), "text/plain")
+	uploadObject("tree-bucket", "dir/c.txt", []byte("c"), "text/plain")
+	uploadObject("tree-bucket", "dir/sub/d.txt", []byte("d"), "text/plain")
+
+	req := httptest.NewRequest("GET", "/storage/v1/b/tree-bucket/o?prefix=dir/&delimiter=/", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response GCSListResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if len(response.Items) != 2 {
+		t.Errorf("expected 2 items, got %d: %+v", len(response.Items), response.Items)
+	}
+	if len(response.Prefixes) != 1 || response.Prefixes[0] != "dir/sub/" {
+		t.Errorf("expected prefixes ['dir/sub/'], got %+v", response.Prefixes)
+	}
+}
+
+func TestListObjectsPagination(t *testing.T) {
+	mux := setupTestServer()
+	createBucket("page-bucket")
+	uploadObject("page-bucket", "a.txt", []byte("a"), "text/plain")
+	uploadObject("page-bucket", "b.txt", []byte("b"), "text/plain")
+	uploadObject("page-bucket", "c.txt", []byte("c"), "text/plain")
+
+	req := httptest.NewRequest("GET", "/storage/v1/b/page-bucket/o?maxResults=2", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var page1 GCSListResponse
+	if err := json.NewDecoder(w.Body).Decode(&page1); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(page1.Items) != 2 {
+		t.Fatalf("expected 2 items on page 1, got %d", len(page1.Items))
+	}
+	if page1.NextPageToken == "" {
+		t.Fatal("expected a nextPageToken on page 1")
+	}
+
+	req = httptest.NewRequest("GET", fmt.Sprintf("/storage/v1/b/page-bucket/o?maxResults=2&pageToken=%s", url.QueryEscape(page1.NextPageToken)), nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var page2 GCSListResponse
+	if err := json.NewDecoder(w.Body).Decode(&page2); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(page2.Items) != 1 {
+		t.Fatalf("expected 1 item on page 2, got %d", len(page2.Items))
+	}
+	if page2.NextPageToken != "" {
+		t.Error("expected no nextPageToken on the final page")
+	}
+}
+
+func TestListObjectsDelimiterPaginationNoDuplicatePrefixes(t *testing.T) {
+	mux := setupTestServer()
+	createBucket("group-bucket")
+	uploadObject("group-bucket", "a/1", []byte("1"), "text/plain")
+	uploadObject("group-bucket", "a/2", []byte("2"), "text/plain")
+	uploadObject("group-bucket", "b/1", []byte("1"), "text/plain")
+	uploadObject("group-bucket", "b/2", []byte("2"), "text/plain")
+	uploadObject("group-bucket", "c", []byte("c"), "text/plain")
+
+	req := httptest.NewRequest("GET", "/storage/v1/b/group-bucket/o?delimiter=/&maxResults=2", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var page1 GCSListResponse
+	if err := json.NewDecoder(w.Body).Decode(&page1); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(page1.Prefixes) != 2 || page1.Prefixes[0] != "a/" || page1.Prefixes[1] != "b/" {
+		t.Fatalf("expected prefixes ['a/', 'b/'] on page 1, got %+v", page1.Prefixes)
+	}
+	if page1.NextPageToken == "" {
+		t.Fatal("expected a nextPageToken on page 1")
+	}
+
+	req = httptest.NewRequest("GET", fmt.Sprintf("/storage/v1/b/group-bucket/o?delimiter=/&maxResults=2&pageToken=%s", url.QueryEscape(page1.NextPageToken)), nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var page2 GCSListResponse
+	if err := json.NewDecoder(w.Body).Decode(&page2); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	for _, p := range page2.Prefixes {
+		if p == "a/" || p == "b/" {
+			t.Errorf("page 2 re-emitted already-returned prefix %q", p)
+		}
+	}
+	if len(page2.Items) != 1 || page2.Items[0].Name != "c" {
+		t.Errorf("expected page 2 to contain only 'c', got %+v", page2.Items)
+	}
+}
+
+func TestGetObjectRange(t *testing.T) {
+	mux := setupTestServer()
+	createBucket("range-bucket")
+	uploadObject("range-bucket", "file.txt", []byte("0123456789"), "text/plain")
+
+	req := httptest.NewRequest("GET", "/storage/v1/b/range-bucket/o/file.txt", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expected status 206, got %d", w.Code)
+	}
+	if w.Body.String() != "234" {
+		t.Errorf("expected body '234', got %q", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 2-4/10" {
+		t.Errorf("expected Content-Range 'bytes 2-4/10', got %q", got)
+	}
+
+	// suffix range
+	req = httptest.NewRequest("GET", "/storage/v1/b/range-bucket/o/file.txt", nil)
+	req.Header.Set("Range", "bytes=-3")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expected status 206 for suffix range, got %d", w.Code)
+	}
+	if w.Body.String() != "789" {
+		t.Errorf("expected body '789', got %q", w.Body.String())
+	}
+}
+
+func TestGetObjectConditionalHeaders(t *testing.T) {
+	mux := setupTestServer()
+	createBucket("cond-bucket")
+	uploadObject("cond-bucket", "file.txt", []byte("hello"), "text/plain")
+
+	req := httptest.NewRequest("GET", "/storage/v1/b/cond-bucket/o/file.txt", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag header")
+	}
+
+	req = httptest.NewRequest("GET", "/storage/v1/b/cond-bucket/o/file.txt", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/storage/v1/b/cond-bucket/o/file.txt", nil)
+	req.Header.Set("If-Match", `"not-the-etag"`)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status 412, got %d", w.Code)
+	}
+}
+
+func TestUploadWithGenerationPreconditions(t *testing.T) {
+	mux := setupTestServer()
+	createBucket("gen-bucket")
+
+	// ifGenerationMatch=0 requires the object not already exist.
+	req := httptest.NewRequest("POST", "/upload/storage/v1/b/gen-bucket/o?name=file.txt&ifGenerationMatch=0", bytes.NewBufferString("v1"))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for initial create, got %d: %s", w.Code, w.Body.String())
+	}
+	var first GCSObject
+	if err := json.NewDecoder(w.Body).Decode(&first); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if first.Generation != "1" {
+		t.Errorf("expected generation '1', got %q", first.Generation)
+	}
+
+	// re-applying ifGenerationMatch=0 must now fail, since the object exists.
+	req = httptest.NewRequest("POST", "/upload/storage/v1/b/gen-bucket/o?name=file.txt&ifGenerationMatch=0", bytes.NewBufferString("v2"))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status 412, got %d", w.Code)
+	}
+
+	// the correct current generation succeeds and bumps the generation.
+	req = httptest.NewRequest("POST", fmt.Sprintf("/upload/storage/v1/b/gen-bucket/o?name=file.txt&ifGenerationMatch=%s", first.Generation), bytes.NewBufferString("v2"))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var second GCSObject
+	if err := json.NewDecoder(w.Body).Decode(&second); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if second.Generation != "2" {
+		t.Errorf("expected generation '2', got %q", second.Generation)
+	}
+}
+
+func TestDeleteObject(t *testing.T) {
+	mux := setupTestServer()
+	createBucket("del-bucket")
+	uploadObject("del-bucket", "file.txt", []byte("data"), "text/plain")
+
+	req := httptest.NewRequest("DELETE", "/storage/v1/b/del-bucket/o/file.txt", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/storage/v1/b/del-bucket/o/file.txt", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after delete, got %d", w.Code)
+	}
+}
+
+func TestGetObjectByGeneration(t *testing.T) {
+	mux := setupTestServer()
+
+	req := httptest.NewRequest("POST", "/storage/v1/b", bytes.NewBufferString(`{"name": "versioned-bucket", "versioning": {"enabled": true}}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 creating bucket, got %d", w.Code)
+	}
+
+	uploadObject("versioned-bucket", "file.txt", []byte("v1"), "text/plain")
+	firstGen := inMemoryStore["versioned-bucket"]["file.txt"].Generation
+	uploadObject("versioned-bucket", "file.txt", []byte("v2"), "text/plain")
+
+	req = httptest.NewRequest("GET", fmt.Sprintf("/storage/v1/b/versioned-bucket/o/file.txt?generation=%d", firstGen), nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 fetching historical generation, got %d", w.Code)
+	}
+	if w.Body.String() != "v1" {
+		t.Errorf("expected historical body 'v1', got %q", w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/storage/v1/b/versioned-bucket/o/file.txt", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Body.String() != "v2" {
+		t.Errorf("expected live body 'v2', got %q", w.Body.String())
+	}
+}